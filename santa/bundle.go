@@ -0,0 +1,125 @@
+package santa
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BundleMediaTypeV1 is the media_type of the Bundle format defined by this package. Future
+// incompatible changes to the bundle shape should introduce a new media type (ie.
+// ".../v2+json") rather than changing the meaning of v1 in place.
+const BundleMediaTypeV1 = "application/vnd.moroz.rulebundle.v1+json"
+
+// Bundle is a signed, versioned snapshot of a Preflight and its Rules. A sync server serves a
+// Bundle alongside a detached signature so that a client-side tool can verify the rule set was
+// produced by a trusted key before applying it, rather than trusting the sync response as-is.
+type Bundle struct {
+	MediaType string    `json:"media_type"`
+	Version   int       `json:"version"`
+	IssuedAt  time.Time `json:"issued_at"`
+	Preflight Preflight `json:"preflight"`
+	Rules     []Rule    `json:"rules"`
+}
+
+// NewBundle returns a v1 Bundle wrapping preflight and rules, issued at issuedAt.
+func NewBundle(preflight Preflight, rules []Rule, issuedAt time.Time) Bundle {
+	return Bundle{
+		MediaType: BundleMediaTypeV1,
+		Version:   1,
+		IssuedAt:  issuedAt,
+		Preflight: preflight,
+		Rules:     rules,
+	}
+}
+
+// canonical returns the bytes of the Bundle that are signed and verified. Struct field order is
+// fixed by the Bundle type declaration, so plain json.Marshal is already canonical: two Bundles
+// with the same field values always marshal to the same bytes.
+func (b Bundle) canonical() ([]byte, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling bundle")
+	}
+	return data, nil
+}
+
+// TrustedKey is a single Ed25519 public key accepted by a TrustRoot, valid for the half-open
+// interval [NotBefore, NotAfter). A zero NotAfter means the key has no expiry. Overlapping
+// validity windows across two TrustedKeys are how key rotation is expressed: publish the new
+// key's window before the old key's ends.
+type TrustedKey struct {
+	ID        string            `json:"id"`
+	PublicKey ed25519.PublicKey `json:"public_key"`
+	NotBefore time.Time         `json:"not_before"`
+	NotAfter  time.Time         `json:"not_after,omitempty"`
+}
+
+// validAt reports whether the key is within its validity window at t.
+func (k TrustedKey) validAt(t time.Time) bool {
+	if t.Before(k.NotBefore) {
+		return false
+	}
+	return k.NotAfter.IsZero() || t.Before(k.NotAfter)
+}
+
+// TrustRoot is the set of Ed25519 public keys a client or sync server accepts Bundle signatures
+// from, each with its own validity window.
+type TrustRoot struct {
+	Keys []TrustedKey `json:"keys"`
+}
+
+// ActiveKeys returns the keys in the TrustRoot whose validity window contains at.
+func (t TrustRoot) ActiveKeys(at time.Time) []TrustedKey {
+	var active []TrustedKey
+	for _, k := range t.Keys {
+		if k.validAt(at) {
+			active = append(active, k)
+		}
+	}
+	return active
+}
+
+// SignBundle signs bundle's canonical form with priv, returning the detached signature.
+func SignBundle(priv ed25519.PrivateKey, bundle Bundle) ([]byte, error) {
+	data, err := bundle.canonical()
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(priv, data), nil
+}
+
+// VerifyBundle checks sig against bundle's canonical form using every key in root that is valid
+// as of bundle.IssuedAt, returning the ID of the key that verified it. It returns an error if no
+// active key verifies the signature, ie. the bundle is tampered, unsigned, or signed by a key
+// that has been rotated out.
+//
+// A valid signature alone does not rule out replay: an old bundle that was legitimately signed
+// (ie. with weaker rules, since superseded) verifies just as well as the newest one. Callers MUST
+// track the highest Version they have ever accepted per TrustRoot and pass it as minVersion, and
+// pass the current time as now, so a captured old bundle can't be fed back in as a rollback.
+func VerifyBundle(root TrustRoot, bundle Bundle, sig []byte, now time.Time, minVersion int) (string, error) {
+	if bundle.IssuedAt.After(now) {
+		return "", errors.Errorf("bundle issued_at %s is in the future", bundle.IssuedAt)
+	}
+	if bundle.Version < minVersion {
+		return "", errors.Errorf("bundle version %d is older than the last accepted version %d (possible rollback)", bundle.Version, minVersion)
+	}
+
+	data, err := bundle.canonical()
+	if err != nil {
+		return "", err
+	}
+	active := root.ActiveKeys(bundle.IssuedAt)
+	if len(active) == 0 {
+		return "", errors.New("no trust root keys are valid at bundle issuance time")
+	}
+	for _, k := range active {
+		if ed25519.Verify(k.PublicKey, data, sig) {
+			return k.ID, nil
+		}
+	}
+	return "", errors.New("no trusted key verifies the bundle signature")
+}
@@ -0,0 +1,135 @@
+package santa
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+)
+
+func mustGenerateKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generating ed25519 key: %v", err)
+	}
+	return pub, priv
+}
+
+func TestSignBundleVerifyBundleRoundTrip(t *testing.T) {
+	pub, priv := mustGenerateKey(t)
+	issuedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := issuedAt.Add(time.Hour)
+
+	bundle := NewBundle(Preflight{ClientMode: Lockdown}, []Rule{{RuleType: Binary, Policy: Allowlist, Identifier: sha256Fixture}}, issuedAt)
+	sig, err := SignBundle(priv, bundle)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+
+	root := TrustRoot{Keys: []TrustedKey{{ID: "key-1", PublicKey: pub, NotBefore: issuedAt.Add(-time.Hour)}}}
+
+	keyID, err := VerifyBundle(root, bundle, sig, now, 0)
+	if err != nil {
+		t.Fatalf("VerifyBundle: %v", err)
+	}
+	if keyID != "key-1" {
+		t.Errorf("keyID = %q, want %q", keyID, "key-1")
+	}
+}
+
+func TestVerifyBundleRejectsTamperedSignature(t *testing.T) {
+	pub, priv := mustGenerateKey(t)
+	issuedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := issuedAt.Add(time.Hour)
+
+	bundle := NewBundle(Preflight{ClientMode: Lockdown}, nil, issuedAt)
+	sig, err := SignBundle(priv, bundle)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+
+	tampered := bundle
+	tampered.Preflight.ClientMode = Monitor
+
+	root := TrustRoot{Keys: []TrustedKey{{ID: "key-1", PublicKey: pub, NotBefore: issuedAt.Add(-time.Hour)}}}
+
+	if _, err := VerifyBundle(root, tampered, sig, now, 0); err == nil {
+		t.Fatal("VerifyBundle accepted a bundle that was modified after signing")
+	}
+}
+
+func TestVerifyBundleKeyRotationWindow(t *testing.T) {
+	oldPub, oldPriv := mustGenerateKey(t)
+	newPub, _ := mustGenerateKey(t)
+
+	oldIssuedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotatedAt := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	root := TrustRoot{Keys: []TrustedKey{
+		{ID: "old-key", PublicKey: oldPub, NotBefore: oldIssuedAt.Add(-24 * time.Hour), NotAfter: rotatedAt},
+		{ID: "new-key", PublicKey: newPub, NotBefore: rotatedAt.Add(-24 * time.Hour)},
+	}}
+
+	// A bundle signed by the old key before rotation still verifies, since the old key was
+	// valid at the bundle's issuance time.
+	bundle := NewBundle(Preflight{}, nil, oldIssuedAt)
+	sig, err := SignBundle(oldPriv, bundle)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+	if _, err := VerifyBundle(root, bundle, sig, rotatedAt.Add(time.Hour), 0); err != nil {
+		t.Errorf("VerifyBundle rejected a bundle signed within its key's validity window: %v", err)
+	}
+
+	// The same old key can no longer sign bundles issued after its validity window ends.
+	afterRotation := NewBundle(Preflight{}, nil, rotatedAt.Add(time.Hour))
+	sig2, err := SignBundle(oldPriv, afterRotation)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+	if _, err := VerifyBundle(root, afterRotation, sig2, rotatedAt.Add(2*time.Hour), 0); err == nil {
+		t.Error("VerifyBundle accepted a bundle issued after its signing key's NotAfter")
+	}
+}
+
+func TestVerifyBundleRejectsFutureIssuedAt(t *testing.T) {
+	pub, priv := mustGenerateKey(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	issuedAt := now.Add(time.Hour)
+
+	bundle := NewBundle(Preflight{}, nil, issuedAt)
+	sig, err := SignBundle(priv, bundle)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+
+	root := TrustRoot{Keys: []TrustedKey{{ID: "key-1", PublicKey: pub, NotBefore: now.Add(-time.Hour)}}}
+
+	if _, err := VerifyBundle(root, bundle, sig, now, 0); err == nil {
+		t.Fatal("VerifyBundle accepted a bundle issued in the future")
+	}
+}
+
+func TestVerifyBundleRejectsStaleVersion(t *testing.T) {
+	pub, priv := mustGenerateKey(t)
+	issuedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := issuedAt.Add(time.Hour)
+
+	bundle := NewBundle(Preflight{}, nil, issuedAt)
+	bundle.Version = 3
+	sig, err := SignBundle(priv, bundle)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+
+	root := TrustRoot{Keys: []TrustedKey{{ID: "key-1", PublicKey: pub, NotBefore: issuedAt.Add(-time.Hour)}}}
+
+	if _, err := VerifyBundle(root, bundle, sig, now, 4); err == nil {
+		t.Fatal("VerifyBundle accepted a bundle whose Version is older than the last accepted version")
+	}
+	if _, err := VerifyBundle(root, bundle, sig, now, 3); err != nil {
+		t.Errorf("VerifyBundle rejected a bundle whose Version matches the last accepted version: %v", err)
+	}
+}
+
+const sha256Fixture = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
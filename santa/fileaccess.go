@@ -0,0 +1,77 @@
+package santa
+
+import "github.com/pkg/errors"
+
+// FileAccessPolicy represents the action a FileAccessRule takes when a process outside its
+// AllowedSigningIDs attempts to access a matching path.
+type FileAccessPolicy int
+
+const (
+	// FileAccessBlock denies the access attempt.
+	FileAccessBlock FileAccessPolicy = iota
+
+	// FileAccessAuditOnly logs the access attempt without denying it.
+	FileAccessAuditOnly
+)
+
+func (p *FileAccessPolicy) UnmarshalText(text []byte) error {
+	switch t := string(text); t {
+	case "BLOCK":
+		*p = FileAccessBlock
+	case "AUDIT_ONLY":
+		*p = FileAccessAuditOnly
+	default:
+		return errors.Errorf("unknown file_access policy value %q", t)
+	}
+	return nil
+}
+
+func (p FileAccessPolicy) MarshalText() ([]byte, error) {
+	switch p {
+	case FileAccessBlock:
+		return []byte("BLOCK"), nil
+	case FileAccessAuditOnly:
+		return []byte("AUDIT_ONLY"), nil
+	default:
+		return nil, errors.Errorf("unknown file_access policy %d", p)
+	}
+}
+
+// FileAccessRule is a Santa file access auth rule: a glob of paths to protect, and the set of
+// code-signing IDs allowed to access paths matching that glob. Santa evaluates file access rules
+// independently of the Binary/Certificate/TeamID/SigningID/CDHash execution Rules, so they are
+// parsed via LoadFileAccessRules from CSV/JSON catalogs in the same formats as execution rules,
+// kept in their own type and served separately. A file access catalog is a separate file from an
+// execution rule catalog.
+//
+// Preflight.OverrideFileAccessAction, when set, overrides every rule's Action fleet- or
+// group-wide without having to edit each rule.
+type FileAccessRule struct {
+	Name              string           `json:"name" toml:"name"`
+	PathGlob          string           `json:"path_glob" toml:"path_glob"`
+	AllowedSigningIDs []string         `json:"allowed_signing_ids,omitempty" toml:"allowed_signing_ids,omitempty"`
+	Action            FileAccessPolicy `json:"action" toml:"action"`
+	CustomMessage     string           `json:"custom_msg,omitempty" toml:"custom_msg,omitempty"`
+}
+
+// Validate checks that the rule has a Name, a PathGlob, and that every entry in
+// AllowedSigningIDs is a well-formed SigningID identifier.
+func (f FileAccessRule) Validate() error {
+	if f.Name == "" {
+		return errors.New("file access rule is missing a name")
+	}
+	if f.PathGlob == "" {
+		return errors.Errorf("file access rule %q is missing a path_glob", f.Name)
+	}
+	for _, id := range f.AllowedSigningIDs {
+		if err := ValidateIdentifier(SigningID, id); err != nil {
+			return errors.Wrapf(err, "file access rule %q: allowed_signing_ids", f.Name)
+		}
+	}
+	return nil
+}
+
+// FileAccessResponse is the body served by the sync server's /fileaccess/:machine_id endpoint.
+type FileAccessResponse struct {
+	Rules []FileAccessRule `json:"rules"`
+}
@@ -0,0 +1,275 @@
+package santa
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Group is a named collection of Preflight and Rule overrides applied to every machine that
+// matches it. Groups replace the one-Config-per-MachineID model: instead of authoring a TOML
+// file per machine, operators author a handful of groups (e.g. "engineering", "kiosk",
+// "lockdown-pilot") and membership is inferred from the PreflightPayload a machine reports.
+type Group struct {
+	Name string `toml:"name" json:"name"`
+
+	// HostnameRegex, PrimaryUserRegex and SerialPrefix are membership criteria matched against
+	// the corresponding PreflightPayload fields. Empty strings are treated as "match everything"
+	// for that criterion.
+	HostnameRegex    string `toml:"hostname_regex,omitempty" json:"hostname_regex,omitempty"`
+	PrimaryUserRegex string `toml:"primary_user_regex,omitempty" json:"primary_user_regex,omitempty"`
+	SerialPrefix     string `toml:"serial_prefix,omitempty" json:"serial_prefix,omitempty"`
+
+	// OSVersionMin and OSVersionMax, if set, bound PreflightPayload.OSVersion (inclusive) as
+	// dotted version numbers, ie. "13.0" <= OSVersion <= "14.99".
+	OSVersionMin string `toml:"os_version_min,omitempty" json:"os_version_min,omitempty"`
+	OSVersionMax string `toml:"os_version_max,omitempty" json:"os_version_max,omitempty"`
+
+	// Preflight is a fragment of Preflight settings to apply when this group matches. Only the
+	// fields explicitly set (non-nil) on the fragment take effect; later matching groups override
+	// earlier ones field-by-field, including overriding back to a field's zero value. See
+	// mergePreflight.
+	Preflight PreflightOverride `toml:"preflight" json:"preflight"`
+
+	// Rules are merged into the composed rule set via RuleSet.Merge: later groups override
+	// earlier rules with the same Identifier, and a REMOVE policy deletes an inherited rule.
+	Rules []Rule `toml:"rules" json:"rules"`
+
+	// FileAccessRules are served alongside Rules, independent of the execution rule merge above.
+	FileAccessRules []FileAccessRule `toml:"file_access_rules,omitempty" json:"file_access_rules,omitempty"`
+}
+
+// PreflightOverride is a fragment of Preflight settings a Group applies when it matches. Every
+// field is a pointer so that "unset" (nil, leave the inherited value alone) is distinguishable
+// from the field's zero value (explicitly reset to false/0/""), ie. a group can force
+// ClientMode back to Monitor or EnableBundles back to false, not just turn things on.
+type PreflightOverride struct {
+	ClientMode               *ClientMode `toml:"client_mode,omitempty" json:"client_mode,omitempty"`
+	BlockedPathRegex         *string     `toml:"blocked_path_regex,omitempty" json:"blocked_path_regex,omitempty"`
+	AllowedPathRegex         *string     `toml:"allowed_path_regex,omitempty" json:"allowed_path_regex,omitempty"`
+	BatchSize                *int        `toml:"batch_size,omitempty" json:"batch_size,omitempty"`
+	EnableBundles            *bool       `toml:"enable_bundles,omitempty" json:"enable_bundles,omitempty"`
+	EnableTransitiveRules    *bool       `toml:"enable_transitive_rules,omitempty" json:"enable_transitive_rules,omitempty"`
+	CleanSync                *bool       `toml:"clean_sync,omitempty" json:"clean_sync,omitempty"`
+	FullSyncInterval         *int        `toml:"full_sync_interval,omitempty" json:"full_sync_interval,omitempty"`
+	BlockUSBMount            *bool       `toml:"block_usb_mount,omitempty" json:"block_usb_mount,omitempty"`
+	RemountUSBMode           *[]string   `toml:"remount_usb_mode,omitempty" json:"remount_usb_mode,omitempty"`
+	OverrideFileAccessAction *string     `toml:"override_file_access_action,omitempty" json:"override_file_access_action,omitempty"`
+	EnableAllEventUpload     *bool       `toml:"enable_all_event_upload,omitempty" json:"enable_all_event_upload,omitempty"`
+}
+
+// Matches reports whether payload satisfies every membership criterion configured on g.
+func (g Group) Matches(payload PreflightPayload) (bool, error) {
+	if g.HostnameRegex != "" {
+		matched, err := regexp.MatchString(g.HostnameRegex, payload.Hostname)
+		if err != nil {
+			return false, errors.Wrapf(err, "group %q: hostname_regex", g.Name)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if g.PrimaryUserRegex != "" {
+		matched, err := regexp.MatchString(g.PrimaryUserRegex, payload.PrimaryUser)
+		if err != nil {
+			return false, errors.Wrapf(err, "group %q: primary_user_regex", g.Name)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if g.SerialPrefix != "" && !strings.HasPrefix(payload.SerialNumber, g.SerialPrefix) {
+		return false, nil
+	}
+	if g.OSVersionMin != "" && compareVersions(payload.OSVersion, g.OSVersionMin) < 0 {
+		return false, nil
+	}
+	if g.OSVersionMax != "" && compareVersions(payload.OSVersion, g.OSVersionMax) > 0 {
+		return false, nil
+	}
+	return true, nil
+}
+
+// compareVersions compares two dotted version strings (ie. "13.2.1") component-wise, returning
+// -1, 0 or 1. Non-numeric or missing components compare as 0, so "13" == "13.0".
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var av, bv int
+		if i < len(as) {
+			av, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			bv, _ = strconv.Atoi(bs[i])
+		}
+		if av != bv {
+			if av < bv {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// Resolver classifies a machine into zero or more Group names based on the PreflightPayload it
+// reports. Operators can plug in custom classification logic (ie. backed by an inventory system)
+// by implementing Resolver themselves instead of using the built-in RegexResolver.
+type Resolver interface {
+	// Resolve returns the names of every Group the payload is a member of, in the order those
+	// groups should be applied (later entries take precedence during merge).
+	Resolve(payload PreflightPayload) ([]string, error)
+}
+
+// RegexResolver is the built-in Resolver, matching a machine against Groups in the order the
+// Groups slice is declared.
+type RegexResolver struct {
+	Groups []Group
+}
+
+// Resolve implements Resolver.
+func (r RegexResolver) Resolve(payload PreflightPayload) ([]string, error) {
+	var names []string
+	for _, g := range r.Groups {
+		matched, err := g.Matches(payload)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			names = append(names, g.Name)
+		}
+	}
+	return names, nil
+}
+
+// RuleSet is the result of merging Rules from every Group a machine belongs to. Rules are keyed
+// by Identifier: a rule contributed by a later group overwrites one with the same Identifier from
+// an earlier group, and a rule with a REMOVE Policy deletes any existing entry rather than being
+// kept itself.
+type RuleSet struct {
+	rules map[string]Rule
+	order []string
+}
+
+// NewRuleSet returns an empty RuleSet.
+func NewRuleSet() *RuleSet {
+	return &RuleSet{rules: make(map[string]Rule)}
+}
+
+// Merge applies rules on top of the RuleSet's current contents, in order.
+func (rs *RuleSet) Merge(rules []Rule) {
+	for _, rule := range rules {
+		if rule.Policy == Remove {
+			if _, ok := rs.rules[rule.Identifier]; ok {
+				delete(rs.rules, rule.Identifier)
+				rs.order = removeString(rs.order, rule.Identifier)
+			}
+			continue
+		}
+		if _, ok := rs.rules[rule.Identifier]; !ok {
+			rs.order = append(rs.order, rule.Identifier)
+		}
+		rs.rules[rule.Identifier] = rule
+	}
+}
+
+// Rules returns the merged rules in the order their identifiers were first introduced.
+func (rs *RuleSet) Rules() []Rule {
+	out := make([]Rule, 0, len(rs.order))
+	for _, id := range rs.order {
+		out = append(out, rs.rules[id])
+	}
+	return out
+}
+
+func removeString(ss []string, s string) []string {
+	out := ss[:0]
+	for _, v := range ss {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Compose resolves payload against resolver, then merges the Preflight and Rules of every
+// matching group (in resolution order) into a single Config. base is used as the starting
+// Preflight before any group is applied, ie. for fleet-wide defaults.
+func Compose(resolver Resolver, groups map[string]Group, base Preflight, payload PreflightPayload) (Config, error) {
+	names, err := resolver.Resolve(payload)
+	if err != nil {
+		return Config{}, errors.Wrap(err, "resolving groups")
+	}
+
+	preflight := base
+	ruleSet := NewRuleSet()
+	fileAccessRules := make(map[string]FileAccessRule)
+	var fileAccessOrder []string
+	for _, name := range names {
+		g, ok := groups[name]
+		if !ok {
+			return Config{}, errors.Errorf("resolver returned unknown group %q", name)
+		}
+		preflight = mergePreflight(preflight, g.Preflight)
+		ruleSet.Merge(g.Rules)
+		for _, far := range g.FileAccessRules {
+			if _, ok := fileAccessRules[far.Name]; !ok {
+				fileAccessOrder = append(fileAccessOrder, far.Name)
+			}
+			fileAccessRules[far.Name] = far
+		}
+	}
+
+	farOut := make([]FileAccessRule, 0, len(fileAccessOrder))
+	for _, name := range fileAccessOrder {
+		farOut = append(farOut, fileAccessRules[name])
+	}
+
+	return Config{Preflight: preflight, Rules: ruleSet.Rules(), FileAccessRules: farOut}, nil
+}
+
+// mergePreflight overlays the explicitly-set fields of override on top of base, so that a group
+// only needs to set the Preflight fields it actually wants to change. A nil field in override
+// leaves base untouched; a non-nil field wins even if it points at a zero value, so a later group
+// can reset a field an earlier group turned on.
+func mergePreflight(base Preflight, override PreflightOverride) Preflight {
+	if override.ClientMode != nil {
+		base.ClientMode = *override.ClientMode
+	}
+	if override.BlockedPathRegex != nil {
+		base.BlockedPathRegex = *override.BlockedPathRegex
+	}
+	if override.AllowedPathRegex != nil {
+		base.AllowedPathRegex = *override.AllowedPathRegex
+	}
+	if override.BatchSize != nil {
+		base.BatchSize = *override.BatchSize
+	}
+	if override.EnableBundles != nil {
+		base.EnableBundles = *override.EnableBundles
+	}
+	if override.EnableTransitiveRules != nil {
+		base.EnableTransitiveRules = *override.EnableTransitiveRules
+	}
+	if override.CleanSync != nil {
+		base.CleanSync = *override.CleanSync
+	}
+	if override.FullSyncInterval != nil {
+		base.FullSyncInterval = *override.FullSyncInterval
+	}
+	if override.BlockUSBMount != nil {
+		base.BlockUSBMount = *override.BlockUSBMount
+	}
+	if override.RemountUSBMode != nil {
+		base.RemountUSBMode = *override.RemountUSBMode
+	}
+	if override.OverrideFileAccessAction != nil {
+		base.OverrideFileAccessAction = *override.OverrideFileAccessAction
+	}
+	if override.EnableAllEventUpload != nil {
+		base.EnableAllEventUpload = *override.EnableAllEventUpload
+	}
+	return base
+}
@@ -0,0 +1,40 @@
+package santa
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergePreflightOverridesToZeroValue(t *testing.T) {
+	lockdown := Lockdown
+	monitor := Monitor
+	yes := true
+	no := false
+
+	base := mergePreflight(Preflight{}, PreflightOverride{
+		ClientMode:    &lockdown,
+		EnableBundles: &yes,
+	})
+	if base.ClientMode != Lockdown || !base.EnableBundles {
+		t.Fatalf("first override not applied: %+v", base)
+	}
+
+	got := mergePreflight(base, PreflightOverride{
+		ClientMode:    &monitor,
+		EnableBundles: &no,
+	})
+	if got.ClientMode != Monitor {
+		t.Errorf("ClientMode = %v, want Monitor (override back to zero value)", got.ClientMode)
+	}
+	if got.EnableBundles {
+		t.Errorf("EnableBundles = true, want false (override back to zero value)")
+	}
+}
+
+func TestMergePreflightLeavesUnsetFieldsAlone(t *testing.T) {
+	base := Preflight{ClientMode: Lockdown, BatchSize: 50}
+	got := mergePreflight(base, PreflightOverride{})
+	if !reflect.DeepEqual(got, base) {
+		t.Fatalf("mergePreflight with empty override changed base: got %+v, want %+v", got, base)
+	}
+}
@@ -0,0 +1,270 @@
+package santa
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ruleRecordColumns is the CSV header/column order written by WriteRules. LoadRules matches
+// columns by header name, so it accepts them in any order.
+var ruleRecordColumns = []string{"type", "policy", "identifier", "custom_msg", "description"}
+
+// RuleRecord is a single entry in an imported rule catalog. Like Rule, it uses one Identifier
+// field regardless of RuleType so that binary hashes, certificate fingerprints, Team IDs,
+// SigningIDs and CDHashes can live in the same CSV/JSON catalog; unlike Rule, it also carries an
+// operator-facing Description.
+//
+// Description is for operators only: LoadRules/WriteRules round-trip it, but Rule() strips it
+// before the record is ever served to a Santa client.
+type RuleRecord struct {
+	Type        RuleType `json:"type"`
+	Policy      Policy   `json:"policy"`
+	Identifier  string   `json:"identifier"`
+	CustomMsg   string   `json:"custom_msg,omitempty"`
+	Description string   `json:"description,omitempty"`
+}
+
+// Rule converts the record to the Rule type served to Santa clients, dropping Description.
+func (rr RuleRecord) Rule() Rule {
+	return Rule{
+		RuleType:      rr.Type,
+		Policy:        rr.Policy,
+		Identifier:    rr.Identifier,
+		CustomMessage: rr.CustomMsg,
+	}
+}
+
+// Validate checks that the record's Identifier is well-formed for its RuleType.
+func (rr RuleRecord) Validate() error {
+	return ValidateIdentifier(rr.Type, rr.Identifier)
+}
+
+// LoadRules parses a rule catalog from r. format must be "csv" or "json"; any other value
+// returns an error. CSV input is expected to have a header row matching ruleRecordColumns, in
+// any order. JSON input must be an array of RuleRecord objects.
+func LoadRules(r io.Reader, format string) ([]RuleRecord, error) {
+	switch format {
+	case "csv":
+		return loadRulesCSV(r)
+	case "json":
+		return loadRulesJSON(r)
+	default:
+		return nil, errors.Errorf("unsupported rule file format %q", format)
+	}
+}
+
+func loadRulesJSON(r io.Reader) ([]RuleRecord, error) {
+	var records []RuleRecord
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, errors.Wrap(err, "decoding json rule catalog")
+	}
+	for i, rec := range records {
+		if err := rec.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "record %d", i)
+		}
+	}
+	return records, nil
+}
+
+func loadRulesCSV(r io.Reader) ([]RuleRecord, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading csv header")
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	for _, required := range []string{"type", "policy", "identifier"} {
+		if _, ok := col[required]; !ok {
+			return nil, errors.Errorf("csv rule catalog missing required column %q", required)
+		}
+	}
+
+	var records []RuleRecord
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading csv row")
+		}
+
+		rec := RuleRecord{Identifier: row[col["identifier"]]}
+		if err := (&rec.Type).UnmarshalText([]byte(row[col["type"]])); err != nil {
+			return nil, errors.Wrapf(err, "row %d", len(records))
+		}
+		if err := (&rec.Policy).UnmarshalText([]byte(row[col["policy"]])); err != nil {
+			return nil, errors.Wrapf(err, "row %d", len(records))
+		}
+		if i, ok := col["custom_msg"]; ok {
+			rec.CustomMsg = row[i]
+		}
+		if i, ok := col["description"]; ok {
+			rec.Description = row[i]
+		}
+		if err := rec.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "row %d", len(records))
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// WriteRules writes records back out in the given format ("csv" or "json"), the inverse of
+// LoadRules.
+func WriteRules(w io.Writer, records []RuleRecord, format string) error {
+	switch format {
+	case "csv":
+		return writeRulesCSV(w, records)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(records), "encoding json rule catalog")
+	default:
+		return errors.Errorf("unsupported rule file format %q", format)
+	}
+}
+
+func writeRulesCSV(w io.Writer, records []RuleRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(ruleRecordColumns); err != nil {
+		return errors.Wrap(err, "writing csv header")
+	}
+	for _, rec := range records {
+		typeText, err := rec.Type.MarshalText()
+		if err != nil {
+			return err
+		}
+		policyText, err := rec.Policy.MarshalText()
+		if err != nil {
+			return err
+		}
+		row := []string{string(typeText), string(policyText), rec.Identifier, rec.CustomMsg, rec.Description}
+		if err := cw.Write(row); err != nil {
+			return errors.Wrap(err, "writing csv row")
+		}
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "flushing csv writer")
+}
+
+// fileAccessRecordColumns is the CSV header/column order written by WriteFileAccessRules.
+// LoadFileAccessRules matches columns by header name, so it accepts them in any order.
+// allowed_signing_ids holds multiple SigningIDs joined by ";".
+var fileAccessRecordColumns = []string{"name", "path_glob", "allowed_signing_ids", "action", "custom_msg"}
+
+// LoadFileAccessRules parses a FileAccessRule catalog from r, in the same CSV/JSON formats as
+// LoadRules. format must be "csv" or "json". The catalog is a file of its own: it needs the
+// name, path_glob and action columns (or fields), so a file of execution rules read by LoadRules
+// is not accepted here, and one file cannot hold both kinds of rule.
+func LoadFileAccessRules(r io.Reader, format string) ([]FileAccessRule, error) {
+	switch format {
+	case "csv":
+		return loadFileAccessRulesCSV(r)
+	case "json":
+		return loadFileAccessRulesJSON(r)
+	default:
+		return nil, errors.Errorf("unsupported rule file format %q", format)
+	}
+}
+
+func loadFileAccessRulesJSON(r io.Reader) ([]FileAccessRule, error) {
+	var rules []FileAccessRule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, errors.Wrap(err, "decoding json file access catalog")
+	}
+	for i, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "record %d", i)
+		}
+	}
+	return rules, nil
+}
+
+func loadFileAccessRulesCSV(r io.Reader) ([]FileAccessRule, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "reading csv header")
+	}
+	col := make(map[string]int, len(header))
+	for i, name := range header {
+		col[name] = i
+	}
+	for _, required := range []string{"name", "path_glob", "action"} {
+		if _, ok := col[required]; !ok {
+			return nil, errors.Errorf("csv file access catalog missing required column %q", required)
+		}
+	}
+
+	var rules []FileAccessRule
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading csv row")
+		}
+
+		rule := FileAccessRule{
+			Name:     row[col["name"]],
+			PathGlob: row[col["path_glob"]],
+		}
+		if err := (&rule.Action).UnmarshalText([]byte(row[col["action"]])); err != nil {
+			return nil, errors.Wrapf(err, "row %d", len(rules))
+		}
+		if i, ok := col["allowed_signing_ids"]; ok && row[i] != "" {
+			rule.AllowedSigningIDs = strings.Split(row[i], ";")
+		}
+		if i, ok := col["custom_msg"]; ok {
+			rule.CustomMessage = row[i]
+		}
+		if err := rule.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "row %d", len(rules))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// WriteFileAccessRules writes rules back out in the given format ("csv" or "json"), the inverse
+// of LoadFileAccessRules.
+func WriteFileAccessRules(w io.Writer, rules []FileAccessRule, format string) error {
+	switch format {
+	case "csv":
+		return writeFileAccessRulesCSV(w, rules)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(rules), "encoding json file access catalog")
+	default:
+		return errors.Errorf("unsupported rule file format %q", format)
+	}
+}
+
+func writeFileAccessRulesCSV(w io.Writer, rules []FileAccessRule) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fileAccessRecordColumns); err != nil {
+		return errors.Wrap(err, "writing csv header")
+	}
+	for _, rule := range rules {
+		actionText, err := rule.Action.MarshalText()
+		if err != nil {
+			return err
+		}
+		row := []string{rule.Name, rule.PathGlob, strings.Join(rule.AllowedSigningIDs, ";"), string(actionText), rule.CustomMessage}
+		if err := cw.Write(row); err != nil {
+			return errors.Wrap(err, "writing csv row")
+		}
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "flushing csv writer")
+}
@@ -3,6 +3,7 @@ package santa
 
 import (
 	"encoding/json"
+	"regexp"
 
 	"github.com/pkg/errors"
 )
@@ -12,7 +13,8 @@ import (
 type Config struct {
 	MachineID string `toml:"machine_id,omitempty"`
 	Preflight
-	Rules []Rule `toml:"rules"`
+	Rules           []Rule           `toml:"rules"`
+	FileAccessRules []FileAccessRule `toml:"file_access_rules,omitempty"`
 }
 
 // Rule is a Santa rule.
@@ -24,6 +26,47 @@ type Rule struct {
 	CustomMessage string   `json:"custom_msg,omitempty" toml:"custom_msg,omitempty"`
 }
 
+var (
+	sha256HexPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+	sha1HexPattern   = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+	teamIDPattern    = regexp.MustCompile(`^[0-9A-Z]{10}$`)
+	signingIDPattern = regexp.MustCompile(`^[0-9A-Z]{10}:.+$`)
+)
+
+// Validate checks that the Rule's Identifier is well-formed for its RuleType. It does not
+// contact Santa clients or the binaries in question, so it cannot catch every way a rule may
+// fail to apply in practice; for CDHash rules in particular, the target binary must also be
+// signed with the CS_KILL or CS_HARD codesigning flags for Santa to enforce it.
+func (r Rule) Validate() error {
+	return ValidateIdentifier(r.RuleType, r.Identifier)
+}
+
+// ValidateIdentifier checks that identifier is a syntactically valid rule identifier for the
+// given RuleType.
+func ValidateIdentifier(t RuleType, identifier string) error {
+	switch t {
+	case Binary, Certificate:
+		if !sha256HexPattern.MatchString(identifier) {
+			return errors.Errorf("identifier %q is not a 64-character SHA-256 hex digest", identifier)
+		}
+	case CDHash:
+		if !sha1HexPattern.MatchString(identifier) {
+			return errors.Errorf("identifier %q is not a 40-character CDHash hex digest", identifier)
+		}
+	case TeamID:
+		if !teamIDPattern.MatchString(identifier) {
+			return errors.Errorf("identifier %q is not a 10-character Team ID", identifier)
+		}
+	case SigningID:
+		if !signingIDPattern.MatchString(identifier) {
+			return errors.Errorf("identifier %q is not a TEAMID:signingid pair", identifier)
+		}
+	default:
+		return errors.Errorf("unknown rule_type %d", t)
+	}
+	return nil
+}
+
 // Preflight representssync response sent to a Santa client by the sync server.
 type Preflight struct {
 	ClientMode            ClientMode `json:"client_mode" toml:"client_mode"`
@@ -34,6 +77,24 @@ type Preflight struct {
 	EnableTransitiveRules bool       `json:"enable_transitive_rules" toml:"enable_transitive_rules"`
 	CleanSync             bool       `json:"clean_sync" toml:"clean_sync"`
 	FullSyncInterval      int        `json:"full_sync_interval" toml:"full_sync_interval"`
+
+	// BlockUSBMount, if true, instructs the client to block the mounting of USB mass storage
+	// devices outright.
+	BlockUSBMount bool `json:"block_usb_mount,omitempty" toml:"block_usb_mount,omitempty"`
+
+	// RemountUSBMode lists the mount options (ie. "rdonly", "noexec") a USB mass storage device
+	// is force-remounted with instead of being blocked. Only consulted when BlockUSBMount is
+	// false; an empty slice leaves USB mounting unrestricted.
+	RemountUSBMode []string `json:"remount_usb_mode,omitempty" toml:"remount_usb_mode,omitempty"`
+
+	// OverrideFileAccessAction overrides the action of every FileAccessRule served to the
+	// client: "AUDIT_ONLY" logs violations without blocking, "DISABLE" turns file access
+	// auditing off entirely, and "" leaves each rule's own Action in effect.
+	OverrideFileAccessAction string `json:"override_file_access_action,omitempty" toml:"override_file_access_action,omitempty"`
+
+	// EnableAllEventUpload, if true, instructs the client to upload telemetry for all executions
+	// it evaluates, not just ones that were blocked or otherwise notable.
+	EnableAllEventUpload bool `json:"enable_all_event_upload,omitempty" toml:"enable_all_event_upload,omitempty"`
 }
 
 // A PreflightPayload represents the request sent by a santa client to the sync server.
@@ -78,6 +139,13 @@ const (
 	// with the Apple developer certificate used to sign the application.
 	// ie. EQHXZ8M8AV:com.google.Chrome
 	SigningID
+
+	// CDHash rules identify a specific version of a signed Mach-O binary via the SHA-1 hash of its code
+	// directory. This is the most specific rule type Santa supports: unlike a Binary rule it is tied to a
+	// single build, and unlike Certificate/TeamID/SigningID rules it grants no reach beyond that build.
+	// CDHash rules only take effect for binaries signed with the CS_KILL or CS_HARD codesigning flags, since
+	// Santa relies on the kernel to enforce that the running code directory matches what was hashed.
+	CDHash
 )
 
 func (r *RuleType) UnmarshalText(text []byte) error {
@@ -90,6 +158,8 @@ func (r *RuleType) UnmarshalText(text []byte) error {
 		*r = TeamID
 	case "SIGNINGID":
 		*r = SigningID
+	case "CDHASH":
+		*r = CDHash
 	default:
 		return errors.Errorf("unknown rule_type value %q", t)
 	}
@@ -106,6 +176,8 @@ func (r RuleType) MarshalText() ([]byte, error) {
 		return []byte("TEAMID"), nil
 	case SigningID:
 		return []byte("SIGNINGID"), nil
+	case CDHash:
+		return []byte("CDHASH"), nil
 	default:
 		return nil, errors.Errorf("unknown rule_type %d", r)
 	}
@@ -119,7 +191,9 @@ const (
 	Allowlist
 
 	// AllowlistCompiler is a Transitive allowlist policy which allows allowlisting binaries created by
-	// a specific compiler. EnabledTransitiveAllowlisting must be set to true in the Preflight first.
+	// a specific compiler. EnableTransitiveRules must be set to true in the Preflight first.
+	// AllowlistCompiler rules are most commonly SigningID rules (ie. a specific compiler binary,
+	// identified by Team ID + signing ID), though a Binary rule may also be marked as a compiler.
 	AllowlistCompiler
 	Remove
 )
@@ -0,0 +1,121 @@
+package santa
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TransitiveRuleEvent is the portion of an UploadEvent request body that identifies a binary
+// produced by a compiler rule the client is allowed to trust transitively: the SigningID of the
+// compiler that produced the binary, and the SHA-256 of the binary it produced.
+//
+// Full documentation: https://github.com/google/santa/blob/main/Docs/details/events.md
+type TransitiveRuleEvent struct {
+	ParentSigningID string `json:"signing_id"`
+	CandidateSHA256 string `json:"file_sha256"`
+}
+
+// GeneratedRule is an auto-generated Binary ALLOWLIST rule produced from a TransitiveRuleEvent.
+// It is tagged with the SigningID of the compiler rule that authorized it and an expiry, so that
+// it can be pruned in bulk if that parent rule is ever removed, or once it expires.
+type GeneratedRule struct {
+	Rule
+	ParentSigningID string    `json:"parent_signing_id"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// Expired reports whether the generated rule's TTL has elapsed as of now.
+func (g GeneratedRule) Expired(now time.Time) bool {
+	return now.After(g.ExpiresAt)
+}
+
+// NewTransitiveRule builds the auto-generated ALLOWLIST Binary rule for a transitive-rule event
+// produced by a client-reported compilation, per the given TTL.
+//
+// Preflight.EnableTransitiveRules must be true for the originating machine, and parentPolicy must
+// be the Policy of the existing rule matching event.ParentSigningID. Callers are expected to have
+// already looked up that parent rule; NewTransitiveRule does not have access to the rule store.
+func NewTransitiveRule(preflight Preflight, parentPolicy Policy, event TransitiveRuleEvent, ttl time.Duration, now time.Time) (GeneratedRule, error) {
+	if !preflight.EnableTransitiveRules {
+		return GeneratedRule{}, errors.New("transitive rules are not enabled for this machine")
+	}
+	if parentPolicy != AllowlistCompiler {
+		return GeneratedRule{}, errors.Errorf("signing id %q is not an ALLOWLIST_COMPILER rule", event.ParentSigningID)
+	}
+	if err := ValidateIdentifier(SigningID, event.ParentSigningID); err != nil {
+		return GeneratedRule{}, errors.Wrap(err, "parent signing id")
+	}
+	if err := ValidateIdentifier(Binary, event.CandidateSHA256); err != nil {
+		return GeneratedRule{}, errors.Wrap(err, "candidate binary sha256")
+	}
+
+	return GeneratedRule{
+		Rule: Rule{
+			RuleType:   Binary,
+			Policy:     Allowlist,
+			Identifier: event.CandidateSHA256,
+		},
+		ParentSigningID: event.ParentSigningID,
+		ExpiresAt:       now.Add(ttl),
+	}, nil
+}
+
+// PruneGeneratedRules returns the subset of rules that should be removed because their parent
+// compiler rule (identified by signing ID) has been removed, or their TTL has expired. It is the
+// decision logic used by the RuleStore-backed PruneParentRemoved below; it takes plain slices so it
+// can also be used by callers that don't have a RuleStore at hand (ie. tests, or a one-off batch
+// job fed from a dump of the store).
+func PruneGeneratedRules(rules []GeneratedRule, removedParentSigningIDs map[string]bool, now time.Time) []GeneratedRule {
+	var pruned []GeneratedRule
+	for _, r := range rules {
+		if removedParentSigningIDs[r.ParentSigningID] || r.Expired(now) {
+			pruned = append(pruned, r)
+		}
+	}
+	return pruned
+}
+
+// RuleStore persists the GeneratedRules produced by transitive allowlisting so they can be served
+// to clients and later pruned, backed by whatever storage a sync server already uses (ie. BoltDB,
+// Postgres).
+type RuleStore interface {
+	// PutGeneratedRule persists rule so it is served to clients until it is deleted or pruned.
+	PutGeneratedRule(rule GeneratedRule) error
+
+	// GeneratedRulesByParent returns every GeneratedRule on record for the given parent
+	// SigningID, ie. to prune them when that compiler rule is removed.
+	GeneratedRulesByParent(parentSigningID string) ([]GeneratedRule, error)
+
+	// DeleteGeneratedRules removes the given rules from the store.
+	DeleteGeneratedRules(rules []GeneratedRule) error
+}
+
+// RecordTransitiveRule builds the auto-generated rule for event via NewTransitiveRule and
+// persists it to store, returning the rule that was stored.
+func RecordTransitiveRule(store RuleStore, preflight Preflight, parentPolicy Policy, event TransitiveRuleEvent, ttl time.Duration, now time.Time) (GeneratedRule, error) {
+	rule, err := NewTransitiveRule(preflight, parentPolicy, event, ttl, now)
+	if err != nil {
+		return GeneratedRule{}, err
+	}
+	if err := store.PutGeneratedRule(rule); err != nil {
+		return GeneratedRule{}, errors.Wrap(err, "persisting generated rule")
+	}
+	return rule, nil
+}
+
+// PruneParentRemoved deletes from store every GeneratedRule whose parent compiler rule was
+// parentSigningID, which the caller must have already confirmed is no longer present. It uses
+// PruneGeneratedRules as its decision logic, so there is a single place that decides whether a
+// generated rule should be pruned.
+func PruneParentRemoved(store RuleStore, parentSigningID string, now time.Time) error {
+	rules, err := store.GeneratedRulesByParent(parentSigningID)
+	if err != nil {
+		return errors.Wrapf(err, "looking up generated rules for parent %q", parentSigningID)
+	}
+	toDelete := PruneGeneratedRules(rules, map[string]bool{parentSigningID: true}, now)
+	if len(toDelete) == 0 {
+		return nil
+	}
+	return errors.Wrapf(store.DeleteGeneratedRules(toDelete), "pruning generated rules for parent %q", parentSigningID)
+}